@@ -136,6 +136,7 @@ type RecordSet struct {
 	data  io.ReadWriter // client provided io interface
 	first *Record       // accessible only by package functions
 	stash *Record       // stashed Record from a client Read() but not yet used
+	tsdb  *tsdbStore    // non-nil when the RecordSet was opened with OpenTSDBRecordSet
 }
 
 // NewRecordSet returns a *Recordset that has an in-memory data buffer for
@@ -248,14 +249,24 @@ func (rs *RecordSet) Stash(rec *Record) {
 // Write calls Write() on the csv.Writer held by the RecordSet and returns an
 // error.  The error is nil on a successful write.  Flush() should be called at
 // the end of necessary Write() calls to ensure the IO buffer flushed.
+// For a RecordSet opened with OpenTSDBRecordSet, Write instead appends rec
+// to the write-ahead log; see tsdbStore.writeWAL.
 func (rs *RecordSet) Write(rec Record) error {
+	if rs.tsdb != nil {
+		return rs.tsdb.writeWAL(rec)
+	}
 	err := rs.w.Write(rec)
 	return err
 }
 
 // Flush empties the buffer in the underlying csv.Writer held by the RecordSet
 // and returns any error that has occurred in a previous write or flush.
+// For a RecordSet opened with OpenTSDBRecordSet, Flush instead flushes and
+// fsyncs the write-ahead log.
 func (rs *RecordSet) Flush() error {
+	if rs.tsdb != nil {
+		return rs.tsdb.flushWAL()
+	}
 	rs.w.Flush()
 	err := rs.w.Error()
 	return err
@@ -325,9 +336,17 @@ func (rs *RecordSet) AppendField(newField string, requiredHeaders []string, gen
 // Close calls close on the unexported RecordSet data handle.
 // It is the responsibility of the RecordSet user to
 // call close.  This is usually accomplished by a call to
-//      defer rs.Close()
+//
+//	defer rs.Close()
+//
 // immediately after creating a NewRecordSet.
 func (rs *RecordSet) Close() error {
+	if rs.tsdb != nil {
+		if err := rs.tsdb.close(); err != nil {
+			return fmt.Errorf("recordset close: %v", err)
+		}
+		return nil
+	}
 	if rs.data == nil {
 		return nil
 	}
@@ -623,17 +642,17 @@ func (bt ByTimestamp) Swap(i, j int) {
 	(*bt.data)[i], (*bt.data)[j] = (*bt.data)[j], (*bt.data)[i]
 }
 
-//Less function to implement the sort.Interface.
+// Less function to implement the sort.Interface.
 func (bt ByTimestamp) Less(i, j int) bool {
 	timeIndex, ok := bt.h.Contains("BaseDateTime")
 	if !ok {
 		panic("bytimestamp: less: headers does not contain BaseDateTime")
 	}
-	t1, err := time.Parse(TimeLayout, (*bt.data)[i][timeIndex])
+	t1, err := (*bt.data)[i].ParseTime(timeIndex)
 	if err != nil {
 		panic(err)
 	}
-	t2, err := time.Parse(TimeLayout, (*bt.data)[j][timeIndex])
+	t2, err := (*bt.data)[j].ParseTime(timeIndex)
 	if err != nil {
 		panic(err)
 	}
@@ -753,6 +772,16 @@ func (h Headers) Equals(h2 Headers) bool {
 	return true
 }
 
+// Hash returns a 64 bit hash/fnv of h's Fields, joined by commas. Record.Parse
+// uses it to cache the column index it resolves for each Report field
+// against a given Headers value, so repeated calls with the same Headers
+// do not repeat that work per Record.
+func (h Headers) Hash() uint64 {
+	h64 := fnv.New64a()
+	h64.Write([]byte(strings.Join(h.Fields, ",")))
+	return h64.Sum64()
+}
+
 // Record wraps the return value from a csv.Reader because many publicly
 // available data sources provide AIS records in large csv files. The Record
 // type and its associate methods allow clients of the package to deal
@@ -814,18 +843,13 @@ func (r Record) ParseInt(index int) (int64, error) {
 	return i, nil
 }
 
-// ParseTime wraps time.Parse with a method to return a time.Time
-// from the index value of a field in the AIS Record.
-// Useful for converting the BaseDateTime from the Record.
-// NOTE: FUTURE VERSIONS OF THIS METHOD SHOULD NOT RELY ON A PACKAGE
-// CONSTANT FOR THE LAYOUT FIELD. THIS FIELD SHOULD BE INFERRED FROM
-// A LIST OF FORMATS SEEN IN COMMON DATASOURCES.
+// ParseTime returns a time.Time from the index value of a field in the
+// Record. It tries DefaultTimeParser's candidate layouts in order (which
+// includes TimeLayout) and so auto-detects the timestamp format rather
+// than assuming every dataset uses MarineCadastre's own layout. Use
+// ParseTimeWith to supply a different *TimeParser.
 func (r Record) ParseTime(index int) (time.Time, error) {
-	t, err := time.Parse(TimeLayout, r[index])
-	if err != nil {
-		return time.Time{}, err
-	}
-	return t, nil
+	return r.ParseTimeWith(index, DefaultTimeParser)
 }
 
 // Value returns the record value for the []string index. For out out bounds idx
@@ -854,77 +878,42 @@ func (r *Record) ValueFrom(hm HeaderMap) (val string, ok bool) {
 	return (*r)[hm.Idx], true
 }
 
-// Parse converts the string record values into an ais.Report.  It
-// takes a set of headers as arguments to identify the fields in
-// the Record.
-// NOTE 1: FUTURE VERSIONS MAY ALSO RETURN A CORRELATION STRUCT SO
-// USERS CAN SEE THE FIELD NAMES THAT WERE USED TO MAKE ASSIGNMENTS
-// TO THE REPORT VALUES.  THIS WOULD BE HELPFUL WHEN THERE ARE MULTIPLE
-// STRING NAMES TO REPRESENT THE SAME RECORD FIELD.  FOR EXAMPLE, SOME
-// DATASETS USE "TIME" INSTEAD OF THE MARINECADASTRE USE OF THE
-// FIELD NAME "BASEDATETIME" BUT BOTH SHOULD MAP TO THE "TIMESTAMP" FIELD
-// OF REPORT.
-// NOTE 2: FUTURE VERSION OF THIS METHOD SHOULD ITERATE OVER THE REPORT
-// STRUCT AND FIND THE REQUIRED FIELDS, NOT RELY ON THE HARDCODED VERSION
-// PRESENTED IN THE FIRST FEW LINES OF THIS FUNCTION WHERE I HAVE A
-// MINIMALLY VIABLE IMPLEMENTATION.
-// func (r Record) Parse(h Headers) (Report, error) {
-// 	requiredFields := []string{"MMSI", "BaseDateTime", "LAT", "LON"}
-// 	fields := make(map[string]int)
-
-// 	for _, field := range requiredFields {
-// 		j, ok := h.Contains(field)
-// 		if !ok {
-// 			return Report{}, fmt.Errorf("record parse: passed headers does not contain required field %s", field)
-// 		}
-// 		fields[field] = j
-// 	}
-// 	mmsi, err := r.ParseInt(fields["MMSI"])
-// 	if err != nil {
-// 		return Report{}, fmt.Errorf("record parse: unable to parse MMSI: %s", err)
-// 	}
-// 	t, err := r.ParseTime(fields["BaseDateTime"])
-// 	if err != nil {
-// 		return Report{}, fmt.Errorf("record parse: unable to parse BaseDateTime: %s", err)
-// 	}
-// 	lat, err := r.ParseFloat(fields["LAT"])
-// 	if err != nil {
-// 		return Report{}, fmt.Errorf("record parse: unable to parse LAT: %s", err)
-// 	}
-// 	lon, err := r.ParseFloat(fields["LON"])
-// 	if err != nil {
-// 		return Report{}, fmt.Errorf("record parse: unable to parse LON: %s", err)
-// 	}
-
-// 	return Report{
-// 		MMSI:      mmsi,
-// 		Lat:       lat,
-// 		Lon:       lon,
-// 		Timestamp: t,
-// 	}, nil
-
-// }
+// Parse converts the string record values into an ais.Report, using the
+// `ais` struct tag on each Report field to find that field's column among
+// h's Fields. See parse.go for the implementation.
 
 // Report is the converted string data from an ais.Record into a series
-// of typed values suitable for data analytics.
+// of typed values suitable for data analytics. It is also the type
+// decoded directly off the wire by Decoder, for callers ingesting a live
+// AIVDM/AIVDO feed rather than a CSV dump.
 // NOTE: THIS SET OF FIELDS WILL EVOLVE OVER TIME TO SUPPORT A LARGER
 // SET OF USE CASES AND ANALYTICS.  DO NOT RELY ON THE ORDER OF THE
 // FIELDS IN THIS TYPE.
-// type Report struct {
-// 	MMSI      int64
-// 	Lat       float64
-// 	Lon       float64
-// 	Timestamp time.Time
-// 	data      []interface{}
-// }
+//
+// The `ais` struct tag on each field lists, in preference order, the
+// Headers names Record.Parse will accept for that field when converting a
+// CSV Record into a Report.
+type Report struct {
+	MMSI      int64     `ais:"MMSI"`
+	Timestamp time.Time `ais:"BaseDateTime|TIMESTAMP|TIME"`
+	Lat       float64   `ais:"LAT"`
+	Lon       float64   `ais:"LON"`
+	SOG       float64   `ais:"SOG"`
+	COG       float64   `ais:"COG"`
+	Heading   int64     `ais:"Heading"`
+	data      []interface{}
+}
 
 // Data returns the Report fields in a slice of interface values.
-// func (rep Report) Data() []interface{} {
-// 	rep.data = []interface{}{
-// 		int64(rep.MMSI),
-// 		time.Time(rep.Timestamp),
-// 		float64(rep.Lat),
-// 		float64(rep.Lon),
-// 	}
-// 	return rep.data
-// }
+func (rep Report) Data() []interface{} {
+	rep.data = []interface{}{
+		rep.MMSI,
+		rep.Timestamp,
+		rep.Lat,
+		rep.Lon,
+		rep.SOG,
+		rep.COG,
+		rep.Heading,
+	}
+	return rep.data
+}
@@ -0,0 +1,143 @@
+package aisprom
+
+import (
+	"io"
+	"time"
+
+	"github.com/giesberge/ais"
+)
+
+// RecordReader is implemented by anything that yields ais.Records one at a
+// time; *ais.RecordSet already satisfies it, so WrapReader can instrument
+// either a CSV-backed or OpenTSDBRecordSet-backed RecordSet.
+type RecordReader interface {
+	Read() (*ais.Record, error)
+}
+
+// Decoder is implemented by anything that decodes ais.Reports one at a
+// time; *ais.Decoder already satisfies it, so WrapDecoder can instrument a
+// live NMEA feed the same way WrapReader instruments a RecordReader.
+type Decoder interface {
+	Decode() (ais.Report, error)
+}
+
+// instrumentedReader wraps a RecordReader, updating m on every Read.
+type instrumentedReader struct {
+	r RecordReader
+	m *Metrics
+}
+
+// WrapReader returns a RecordReader that updates m's RecordsRead,
+// ParseErrors, and RecordLatency collectors around every call to r.Read.
+func (m *Metrics) WrapReader(r RecordReader) RecordReader {
+	return &instrumentedReader{r: r, m: m}
+}
+
+// WrapReader wraps r with the package-level default Metrics; see
+// Metrics.WrapReader. Callers who need their own independent set of
+// collectors should use NewMetrics and its WrapReader method instead.
+func WrapReader(r RecordReader) RecordReader {
+	return defaultMetrics.WrapReader(r)
+}
+
+// Read implements RecordReader for instrumentedReader.
+func (ir *instrumentedReader) Read() (*ais.Record, error) {
+	start := time.Now()
+	rec, err := ir.r.Read()
+	ir.m.RecordLatency.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		if err != io.EOF {
+			ir.m.ParseErrors.WithLabelValues("read").Inc()
+		}
+		return rec, err
+	}
+	ir.m.RecordsRead.Inc()
+	return rec, nil
+}
+
+// trackedMMSIStaleness is how long an MMSI can go without a report before
+// instrumentedDecoder considers it no longer tracked and decrements
+// TrackedMMSIs. It is generous relative to the few-second to few-minute
+// report intervals AIS transceivers normally use, so a vessel that is
+// merely between reports is not aged out while one that has gone out of
+// range or stopped transmitting is.
+const trackedMMSIStaleness = 30 * time.Minute
+
+// trackedMMSISweepInterval bounds how often Decode sweeps lastSeen for
+// stale MMSIs. Sweeping is O(len(lastSeen)), so it is only worth doing
+// occasionally rather than on every report.
+const trackedMMSISweepInterval = trackedMMSIStaleness / 2
+
+// instrumentedDecoder wraps a Decoder, updating m on every Decode and
+// tracking the most recent report time per MMSI so it can observe
+// ReportInterval and age MMSIs out of TrackedMMSIs once they go quiet for
+// longer than trackedMMSIStaleness.
+type instrumentedDecoder struct {
+	d         Decoder
+	m         *Metrics
+	lastSeen  map[int64]time.Time
+	lastSweep time.Time
+}
+
+// WrapDecoder returns a Decoder that updates m's ReportsEmitted,
+// ParseErrors, RecordLatency, ReportInterval, and TrackedMMSIs collectors
+// around every call to d.Decode.
+func (m *Metrics) WrapDecoder(d Decoder) Decoder {
+	return &instrumentedDecoder{d: d, m: m, lastSeen: make(map[int64]time.Time)}
+}
+
+// WrapDecoder wraps d with the package-level default Metrics; see
+// Metrics.WrapDecoder. It takes the Decoder interface rather than a
+// concrete *ais.Decoder - which satisfies it - so a caller's own test
+// double or future decoder implementation can be wrapped the same way.
+// Callers who need their own independent set of collectors should use
+// NewMetrics and its WrapDecoder method instead.
+func WrapDecoder(d Decoder) Decoder {
+	return defaultMetrics.WrapDecoder(d)
+}
+
+// Decode implements Decoder for instrumentedDecoder.
+func (id *instrumentedDecoder) Decode() (ais.Report, error) {
+	start := time.Now()
+	rep, err := id.d.Decode()
+	id.m.RecordLatency.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		if err != io.EOF {
+			id.m.ParseErrors.WithLabelValues("decode").Inc()
+		}
+		return rep, err
+	}
+	id.m.ReportsEmitted.Inc()
+
+	now := time.Now()
+	if last, ok := id.lastSeen[rep.MMSI]; ok {
+		id.m.ReportInterval.Observe(now.Sub(last).Seconds())
+	} else {
+		id.m.TrackedMMSIs.Inc()
+	}
+	id.lastSeen[rep.MMSI] = now
+	id.sweepStaleMMSIs(now)
+
+	return rep, nil
+}
+
+// sweepStaleMMSIs removes MMSIs from lastSeen that have not reported in
+// over trackedMMSIStaleness, decrementing TrackedMMSIs for each so it
+// reflects currently-tracked vessels rather than a cumulative total, and
+// keeping lastSeen from growing without bound over a long-lived process.
+// It only does this work once every trackedMMSISweepInterval.
+func (id *instrumentedDecoder) sweepStaleMMSIs(now time.Time) {
+	if now.Sub(id.lastSweep) < trackedMMSISweepInterval {
+		return
+	}
+	id.lastSweep = now
+
+	for mmsi, last := range id.lastSeen {
+		if now.Sub(last) > trackedMMSIStaleness {
+			delete(id.lastSeen, mmsi)
+			id.m.TrackedMMSIs.Dec()
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package ais
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aisSentence is a single parsed AIVDM/AIVDO NMEA 0183 line, before its
+// fragments (if any) have been reassembled into a complete payload.
+type aisSentence struct {
+	fragCount, fragNum int
+	groupID, channel   string
+	payload            string
+	fillBits           int
+}
+
+// NewSentenceScanner returns a *bufio.Scanner configured to split r into
+// individual NMEA lines, suitable for driving a Decoder from any
+// io.Reader, including a DialAISHub connection, a file, or a serial port.
+func NewSentenceScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 64*1024)
+	return s
+}
+
+// parseAISSentence validates the *XX checksum on a raw "!AIVDM,..." or
+// "!AIVDO,..." line and splits it into an aisSentence.
+func parseAISSentence(line string) (aisSentence, error) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || (line[0] != '!' && line[0] != '$') {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: %q does not start with '!' or '$'", line)
+	}
+
+	star := strings.LastIndexByte(line, '*')
+	if star < 0 || star != len(line)-3 {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: missing *XX checksum in %q", line)
+	}
+	body, checksum := line[:star], line[star+1:]
+
+	var sum byte
+	for i := 1; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	want, err := strconv.ParseUint(checksum, 16, 8)
+	if err != nil || byte(want) != sum {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: checksum mismatch in %q", line)
+	}
+
+	fields := strings.Split(body[1:], ",")
+	if len(fields) < 6 {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: expected at least 6 fields, got %d", len(fields))
+	}
+
+	fragCount, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: fragment count: %v", err)
+	}
+	fragNum, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return aisSentence{}, fmt.Errorf("ais: parse sentence: fragment number: %v", err)
+	}
+	fillBits := 0
+	if last := fields[len(fields)-1]; len(last) > 0 {
+		fillBits, err = strconv.Atoi(last[:1])
+		if err != nil {
+			return aisSentence{}, fmt.Errorf("ais: parse sentence: fill bits: %v", err)
+		}
+	}
+
+	return aisSentence{
+		fragCount: fragCount,
+		fragNum:   fragNum,
+		groupID:   fields[3],
+		channel:   fields[4],
+		payload:   fields[5],
+		fillBits:  fillBits,
+	}, nil
+}
+
+// aisBits is a bitstream unpacked from a 6-bit ASCII armored AIS payload,
+// read most significant bit first per ITU-R M.1371.
+type aisBits []bool
+
+// decodeSixBit unpacks a 6-bit ASCII armored payload into a bitstream,
+// dropping fillBits padding bits from the end.
+func decodeSixBit(payload string, fillBits int) (aisBits, error) {
+	bits := make(aisBits, 0, len(payload)*6)
+	for _, c := range payload {
+		v := int(c) - 48
+		if v > 40 {
+			v -= 8
+		}
+		if v < 0 || v > 63 {
+			return nil, fmt.Errorf("ais: decode payload: %q is not valid 6-bit ASCII", c)
+		}
+		for shift := 5; shift >= 0; shift-- {
+			bits = append(bits, v&(1<<uint(shift)) != 0)
+		}
+	}
+	if fillBits > 0 && fillBits <= len(bits) {
+		bits = bits[:len(bits)-fillBits]
+	}
+	return bits, nil
+}
+
+// uint64At reads n bits starting at bit offset start as an unsigned
+// integer, most significant bit first.
+func (b aisBits) uint64At(start, n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if idx := start + i; idx < len(b) && b[idx] {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// int64At reads n bits starting at start as a two's complement signed
+// integer.
+func (b aisBits) int64At(start, n int) int64 {
+	v := b.uint64At(start, n)
+	if v&(1<<uint(n-1)) != 0 {
+		v -= 1 << uint(n)
+	}
+	return int64(v)
+}
+
+// Decoder reassembles multi-part AIVDM/AIVDO sentences read from an
+// io.Reader into Reports, decoding message types 1/2/3 (Class A position
+// reports), 4 (base station report), 5 (static and voyage data), 18/19
+// (Class B position reports), and 24 (static data report).
+type Decoder struct {
+	scanner *bufio.Scanner
+	pending map[string][]string // keyed by groupID + "|" + channel
+}
+
+// NewDecoder returns a *Decoder that reads AIVDM/AIVDO sentences from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		scanner: NewSentenceScanner(r),
+		pending: make(map[string][]string),
+	}
+}
+
+// DialAISHub opens a TCP connection to addr (host:port) and returns a
+// *Decoder reading sentences from it. This is the entry point for
+// ingesting a live AISHub-style feed rather than a MarineCadastre-style
+// CSV dump.
+func DialAISHub(addr string) (*Decoder, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ais: dial aishub: %v", err)
+	}
+	return NewDecoder(conn), nil
+}
+
+// Decode reads sentences until a complete AIS message has been
+// reassembled and decoded, and returns it as a Report. It returns io.EOF
+// once the underlying reader is exhausted.
+func (d *Decoder) Decode() (Report, error) {
+	for d.scanner.Scan() {
+		line := d.scanner.Text()
+		if line == "" {
+			continue
+		}
+		sentence, err := parseAISSentence(line)
+		if err != nil {
+			continue // malformed sentence; keep reading the feed
+		}
+
+		payload, fillBits, ok := d.assemble(sentence)
+		if !ok {
+			continue
+		}
+
+		bits, err := decodeSixBit(payload, fillBits)
+		if err != nil {
+			continue
+		}
+		rep, err := decodeReport(bits)
+		if err != nil {
+			continue // unsupported or malformed message type; skip
+		}
+		return rep, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Report{}, fmt.Errorf("ais: decode: %v", err)
+	}
+	return Report{}, io.EOF
+}
+
+// assemble feeds a sentence into the decoder's fragment reassembly state,
+// returning the full payload and its trailing fill bit count once every
+// fragment of a multi-part message has arrived.
+func (d *Decoder) assemble(s aisSentence) (payload string, fillBits int, ok bool) {
+	if s.fragCount <= 1 {
+		return s.payload, s.fillBits, true
+	}
+
+	key := s.groupID + "|" + s.channel
+	frags := d.pending[key]
+	if frags == nil {
+		frags = make([]string, s.fragCount)
+	}
+	if s.fragNum < 1 || s.fragNum > len(frags) {
+		delete(d.pending, key)
+		return "", 0, false
+	}
+	frags[s.fragNum-1] = s.payload
+	d.pending[key] = frags
+
+	if s.fragNum != s.fragCount {
+		return "", 0, false
+	}
+	delete(d.pending, key)
+
+	for _, f := range frags {
+		payload += f
+	}
+	return payload, s.fillBits, true
+}
+
+// decodeBaseStationTime decodes the UTC year/month/day/hour/minute/second
+// fields of a type 4 base station report (bits 38-77) into a time.Time. It
+// returns the zero time if any field carries its "not available" sentinel
+// (year 0, or a month/day/hour/minute/second outside its valid range).
+func decodeBaseStationTime(bits aisBits) time.Time {
+	year := int(bits.uint64At(38, 14))
+	month := int(bits.uint64At(52, 4))
+	day := int(bits.uint64At(56, 5))
+	hour := int(bits.uint64At(61, 5))
+	minute := int(bits.uint64At(66, 6))
+	second := int(bits.uint64At(72, 6))
+
+	if year == 0 || month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+}
+
+// decodeReport dispatches on the AIS message type (the first 6 bits of
+// every payload) and unpacks it into a Report.
+func decodeReport(bits aisBits) (Report, error) {
+	kind := bits.uint64At(0, 6)
+	mmsi := int64(bits.uint64At(8, 30))
+
+	switch kind {
+	case 1, 2, 3:
+		// Bits 137-142 carry a UTC second (0-59; 60+ means not available or
+		// some other non-time state), not a full date, so there is nothing
+		// here to populate Report.Timestamp with.
+		return Report{
+			MMSI:    mmsi,
+			Lon:     float64(bits.int64At(61, 28)) / 600000.0,
+			Lat:     float64(bits.int64At(89, 27)) / 600000.0,
+			SOG:     float64(bits.uint64At(50, 10)) / 10.0,
+			COG:     float64(bits.uint64At(116, 12)) / 10.0,
+			Heading: int64(bits.uint64At(128, 9)),
+		}, nil
+	case 18, 19:
+		// Same UTC-second-only limitation as types 1/2/3; Timestamp is left
+		// unset.
+		return Report{
+			MMSI:    mmsi,
+			SOG:     float64(bits.uint64At(46, 10)) / 10.0,
+			Lon:     float64(bits.int64At(57, 28)) / 600000.0,
+			Lat:     float64(bits.int64At(85, 27)) / 600000.0,
+			COG:     float64(bits.uint64At(112, 12)) / 10.0,
+			Heading: int64(bits.uint64At(124, 9)),
+		}, nil
+	case 4:
+		return Report{
+			MMSI:      mmsi,
+			Timestamp: decodeBaseStationTime(bits),
+			Lon:       float64(bits.int64At(79, 28)) / 600000.0,
+			Lat:       float64(bits.int64At(107, 27)) / 600000.0,
+		}, nil
+	case 5, 24:
+		// Static and voyage data carries no kinematic fix; MMSI and the
+		// zero-valued Timestamp are all Report can represent today.
+		return Report{MMSI: mmsi}, nil
+	default:
+		return Report{}, fmt.Errorf("ais: decode report: unsupported message type %d", kind)
+	}
+}
@@ -0,0 +1,127 @@
+package ais
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// geoJSONFeature mirrors the subset of RFC 7946 GeoJSON this file
+// produces: a single Feature with a Point or LineString geometry and a
+// flat string-valued properties map.
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature returns an RFC 7946 GeoJSON Point Feature for the fix in
+// r, reading latitude and longitude from latIdx and lonIdx and attaching
+// props as the feature's properties. GeoJSON coordinates are ordered
+// [longitude, latitude].
+func (r Record) GeoJSONFeature(latIdx, lonIdx int, props map[string]string) ([]byte, error) {
+	lat, err := r.ParseFloat(latIdx)
+	if err != nil {
+		return nil, fmt.Errorf("record: geojson feature: %v", err)
+	}
+	lon, err := r.ParseFloat(lonIdx)
+	if err != nil {
+		return nil, fmt.Errorf("record: geojson feature: %v", err)
+	}
+
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONGeometry{
+			Type:        "Point",
+			Coordinates: [2]float64{lon, lat},
+		},
+		Properties: props,
+	}
+
+	b, err := json.Marshal(feature)
+	if err != nil {
+		return nil, fmt.Errorf("record: geojson feature: %v", err)
+	}
+	return b, nil
+}
+
+// GeoJSONFeatureCollection returns an RFC 7946 GeoJSON FeatureCollection
+// containing a single LineString Feature that traces t's fixes in order.
+// GeoJSON coordinates are ordered [longitude, latitude].
+func (t Track) GeoJSONFeatureCollection() ([]byte, error) {
+	coords := make([][2]float64, 0, len(t.Records))
+	for _, rec := range t.Records {
+		lat, err := rec.ParseFloat(t.LatIndex)
+		if err != nil {
+			return nil, fmt.Errorf("track: geojson feature collection: %v", err)
+		}
+		lon, err := rec.ParseFloat(t.LonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("track: geojson feature collection: %v", err)
+		}
+		coords = append(coords, [2]float64{lon, lat})
+	}
+
+	fc := geoJSONFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "LineString",
+				Coordinates: coords,
+			},
+		}},
+	}
+
+	b, err := json.Marshal(fc)
+	if err != nil {
+		return nil, fmt.Errorf("track: geojson feature collection: %v", err)
+	}
+	return b, nil
+}
+
+// LOC returns a DNS LOC-style human-readable representation of the fix in
+// r (RFC 1876's presentation format), reading latitude and longitude from
+// latIdx and lonIdx, e.g. "40 12 30.000 N 74 0 23.000 W 0m 1m 10000m 10m".
+// Altitude and the size/precision fields are fixed at the defaults RFC
+// 1876 recommends for a single point of unknown extent.
+func (r Record) LOC(latIdx, lonIdx int) (string, error) {
+	lat, err := r.ParseFloat(latIdx)
+	if err != nil {
+		return "", fmt.Errorf("record: loc: %v", err)
+	}
+	lon, err := r.ParseFloat(lonIdx)
+	if err != nil {
+		return "", fmt.Errorf("record: loc: %v", err)
+	}
+
+	return fmt.Sprintf("%s %s 0m 1m 10000m 10m", dms(lat, "N", "S"), dms(lon, "E", "W")), nil
+}
+
+// dms converts a signed decimal-degree coordinate into DNS LOC's
+// "D M S.sss H" presentation format, where H is pos for a non-negative
+// value and neg for a negative one.
+func dms(decimal float64, pos, neg string) string {
+	hemisphere := pos
+	if decimal < 0 {
+		hemisphere = neg
+		decimal = -decimal
+	}
+
+	degrees := math.Floor(decimal)
+	minutesFull := (decimal - degrees) * 60
+	minutes := math.Floor(minutesFull)
+	seconds := (minutesFull - minutes) * 60
+
+	return fmt.Sprintf("%d %d %.3f %s", int(degrees), int(minutes), seconds, hemisphere)
+}
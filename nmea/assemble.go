@@ -0,0 +1,64 @@
+package nmea
+
+import "fmt"
+
+// assemblyKey identifies the set of fragments that belong to the same
+// multi-part AIVDM/AIVDO message. The AIS spec scopes group ids to a
+// channel, so two messages with the same GroupID on different channels
+// must not be merged together.
+type assemblyKey struct {
+	GroupID string
+	Channel string
+}
+
+// assembler reassembles multi-part AIVDM/AIVDO sentences into a single
+// payload string, tracking in-progress messages by their group id until
+// every fragment has arrived.
+type assembler struct {
+	pending map[assemblyKey][]string
+}
+
+// newAssembler returns an empty *assembler.
+func newAssembler() *assembler {
+	return &assembler{pending: make(map[assemblyKey][]string)}
+}
+
+// Add feeds a Sentence into the assembler. It returns ok == true and the
+// concatenated payload (with the final sentence's FillBits) once every
+// fragment of a multi-part message has been received. Single-fragment
+// sentences complete immediately.
+func (a *assembler) Add(s Sentence) (payload string, fillBits int, ok bool, err error) {
+	if s.FragCount <= 1 {
+		return s.Payload, s.FillBits, true, nil
+	}
+
+	key := assemblyKey{GroupID: s.GroupID, Channel: s.Channel}
+	frags := a.pending[key]
+	if frags == nil {
+		frags = make([]string, s.FragCount)
+	}
+	if s.FragNum < 1 || s.FragNum > len(frags) {
+		delete(a.pending, key)
+		return "", 0, false, fmt.Errorf("nmea: assemble: fragment %d out of range for a %d-fragment message", s.FragNum, len(frags))
+	}
+	frags[s.FragNum-1] = s.Payload
+	a.pending[key] = frags
+
+	if s.FragNum != s.FragCount {
+		return "", 0, false, nil
+	}
+
+	for i, f := range frags {
+		if f == "" && i != s.FragCount-1 {
+			delete(a.pending, key)
+			return "", 0, false, fmt.Errorf("nmea: assemble: message %v missing fragment %d", key, i+1)
+		}
+	}
+
+	delete(a.pending, key)
+	var full string
+	for _, f := range frags {
+		full += f
+	}
+	return full, s.FillBits, true, nil
+}
@@ -0,0 +1,62 @@
+package nmea
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Dialer is the interface an Ingester uses to obtain the connection it
+// reads sentences from. Implementing Dialer lets callers plug in TLS,
+// reconnect-with-backoff, or a test double without changing Ingester.
+type Dialer interface {
+	Dial() (net.Conn, error)
+}
+
+// TCPDialer is the default Dialer, connecting to a plain TCP AIS feed such
+// as those published by AISHub or a local AIS receiver.
+type TCPDialer struct {
+	Addr    string        // host:port of the feed
+	Timeout time.Duration // passed to net.DialTimeout; zero means no timeout
+}
+
+// Dial implements the Dialer interface for TCPDialer.
+func (d TCPDialer) Dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", d.Addr, d.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("nmea: tcp dialer: dial %s: %v", d.Addr, err)
+	}
+	return conn, nil
+}
+
+// timeoutConn wraps a net.Conn so that every Read() call resets the
+// connection's read deadline first. Live AIS feeds can stall silently
+// (the TCP connection stays open but no further sentences ever arrive), so
+// without this a blocked Read() would hang Ingest forever.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// newTimeoutConn wraps conn so that each Read() is preceded by
+// conn.SetReadDeadline(time.Now().Add(timeout)). A zero timeout disables
+// the deadline and Read behaves exactly like the wrapped conn.
+func newTimeoutConn(conn net.Conn, timeout time.Duration) *timeoutConn {
+	return &timeoutConn{Conn: conn, timeout: timeout}
+}
+
+// Read implements io.Reader, resetting the read deadline before delegating
+// to the wrapped connection.
+func (c *timeoutConn) Read(p []byte) (int, error) {
+	if c.timeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+			return 0, fmt.Errorf("nmea: timeout conn: set read deadline: %v", err)
+		}
+	}
+	n, err := c.Conn.Read(p)
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("nmea: timeout conn: read: %v", err)
+	}
+	return n, err
+}
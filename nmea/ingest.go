@@ -0,0 +1,100 @@
+package nmea
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/giesberge/ais"
+)
+
+// Ingester reads AIVDM/AIVDO sentences from a live feed and writes decoded
+// position reports into an *ais.RecordSet, joining static (type 5/24) and
+// kinematic (type 1/2/3/18/19) reports by MMSI along the way.
+type Ingester struct {
+	// Dialer obtains the connection Ingest reads from. Required for
+	// Ingest; IngestReader does not use it.
+	Dialer Dialer
+
+	// ReadTimeout bounds every individual Read() on the dialed
+	// connection. A stalled upstream feed then makes Ingest return a clean
+	// timeout error instead of blocking forever, matching
+	// the ReadTimeout pattern used by the carbon plain/pickle inputs.
+	ReadTimeout time.Duration
+
+	assembler *assembler
+	merger    *merger
+}
+
+// NewIngester returns an *Ingester that dials feeds with d.
+func NewIngester(d Dialer) *Ingester {
+	return &Ingester{Dialer: d}
+}
+
+// Ingest dials i.Dialer, wraps the connection so every Read() enforces
+// i.ReadTimeout, and streams decoded records into rs until the connection
+// is closed or a read times out.
+func (i *Ingester) Ingest(rs *ais.RecordSet) error {
+	if i.Dialer == nil {
+		return fmt.Errorf("nmea: ingest: no Dialer configured")
+	}
+	conn, err := i.Dialer.Dial()
+	if err != nil {
+		return fmt.Errorf("nmea: ingest: %v", err)
+	}
+	defer conn.Close()
+
+	return i.IngestReader(newTimeoutConn(conn, i.ReadTimeout), rs)
+}
+
+// IngestReader reads newline-delimited AIVDM/AIVDO sentences from r,
+// decodes them, and writes the resulting records into rs. It returns when
+// r returns an error (io.EOF on a clean close, or a deadline-exceeded
+// error from a stalled feed wrapped in a timeoutConn).
+func (i *Ingester) IngestReader(r io.Reader, rs *ais.RecordSet) error {
+	if i.assembler == nil {
+		i.assembler = newAssembler()
+	}
+	if i.merger == nil {
+		i.merger = newMerger()
+	}
+	rs.SetHeaders(Headers)
+
+	scanner := NewSentenceScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		sentence, err := ParseSentence(line)
+		if err != nil {
+			continue // malformed sentence; skip and keep reading the feed
+		}
+
+		payload, fillBits, ok, err := i.assembler.Add(sentence)
+		if err != nil || !ok {
+			continue
+		}
+
+		bs, err := decodePayload(payload, fillBits)
+		if err != nil {
+			continue
+		}
+		rep, err := decodeMessage(bs)
+		if err != nil {
+			continue // unsupported or malformed message type; skip
+		}
+
+		rec, ok := i.merger.Merge(rep, time.Now())
+		if !ok {
+			continue
+		}
+		if err := rs.Write(rec); err != nil {
+			return fmt.Errorf("nmea: ingest: write: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("nmea: ingest: %v", err)
+	}
+	return rs.Flush()
+}
@@ -0,0 +1,106 @@
+package nmea
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/giesberge/ais"
+)
+
+// Headers are the ais.Record field names an Ingester writes, matching the
+// MarineCadastre CSV schema so live-feed RecordSets and historical-dump
+// RecordSets can be used interchangeably.
+var Headers = ais.Headers{Fields: []string{
+	"MMSI", "BaseDateTime", "LAT", "LON", "SOG", "COG", "Heading",
+	"VesselName", "IMO", "CallSign", "VesselType", "Length", "Width",
+	"Draft", "Cargo", "Status",
+}}
+
+// vesselInfo holds the most recently received static/voyage fields (type 5
+// or 24) for a single MMSI, so they can be joined onto that vessel's
+// kinematic reports as they arrive.
+type vesselInfo struct {
+	VesselName, CallSign string
+	IMO                  int64
+	VesselType           int64
+	Length, Width        int64
+	Draft                float64
+	Cargo                int64
+}
+
+// merger joins static reports (type 5/24) with kinematic reports (type
+// 1/2/3/18/19) keyed by MMSI, so a kinematic-only sentence can still
+// produce a fully populated Record once a static report for that MMSI has
+// been seen.
+type merger struct {
+	static map[int64]vesselInfo
+}
+
+// newMerger returns an empty *merger.
+func newMerger() *merger {
+	return &merger{static: make(map[int64]vesselInfo)}
+}
+
+// Merge folds r into the merger's state. When r carries a kinematic fix it
+// returns a complete ais.Record (using any static fields previously seen
+// for r.MMSI) and ok == true. Static-only reports update internal state and
+// return ok == false since they do not represent a position fix to emit.
+func (m *merger) Merge(r report, at time.Time) (rec ais.Record, ok bool) {
+	if r.HasStatic {
+		info := m.static[r.MMSI]
+		if r.VesselName != "" {
+			info.VesselName = r.VesselName
+		}
+		if r.CallSign != "" {
+			info.CallSign = r.CallSign
+		}
+		if r.IMO != 0 {
+			info.IMO = r.IMO
+		}
+		if r.VesselType != 0 {
+			info.VesselType = r.VesselType
+		}
+		if r.Length != 0 {
+			info.Length = r.Length
+		}
+		if r.Width != 0 {
+			info.Width = r.Width
+		}
+		if r.Draft != 0 {
+			info.Draft = r.Draft
+		}
+		if r.Cargo != 0 {
+			info.Cargo = r.Cargo
+		}
+		m.static[r.MMSI] = info
+	}
+
+	if !r.HasKinematic {
+		return nil, false
+	}
+
+	info := m.static[r.MMSI]
+	status := ""
+	if r.HasNavStatus {
+		status = strconv.FormatInt(r.NavStatus, 10)
+	}
+
+	return ais.Record{
+		strconv.FormatInt(r.MMSI, 10),
+		at.UTC().Format(ais.TimeLayout),
+		strconv.FormatFloat(r.Lat, 'f', -1, 64),
+		strconv.FormatFloat(r.Lon, 'f', -1, 64),
+		strconv.FormatFloat(r.SOG, 'f', -1, 64),
+		strconv.FormatFloat(r.COG, 'f', -1, 64),
+		strconv.FormatInt(r.Heading, 10),
+		info.VesselName,
+		strconv.FormatInt(info.IMO, 10),
+		info.CallSign,
+		strconv.FormatInt(info.VesselType, 10),
+		strconv.FormatInt(info.Length, 10),
+		strconv.FormatInt(info.Width, 10),
+		strconv.FormatFloat(info.Draft, 'f', -1, 64),
+		strconv.FormatInt(info.Cargo, 10),
+		status,
+	}, true
+}
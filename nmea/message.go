@@ -0,0 +1,105 @@
+package nmea
+
+import "fmt"
+
+// report is the set of fields this package can recover from a single
+// decoded AIS message. Not every message type populates every field;
+// HasKinematic, HasStatic, and HasNavStatus tell the merger which ones to
+// trust.
+type report struct {
+	Kind uint64 // AIS message type, 1-27
+
+	MMSI int64
+
+	// Kinematic fields, present on types 1/2/3/18/19.
+	Lat, Lon     float64
+	SOG, COG     float64
+	Heading      int64
+	HasKinematic bool
+
+	// Static/voyage fields, present on types 5/24.
+	VesselName, CallSign string
+	IMO                  int64
+	VesselType           int64
+	Length, Width        int64
+	Draft                float64
+	Cargo                int64
+	HasStatic            bool
+
+	// Navigational status, present on types 1/2/3.
+	NavStatus    int64
+	HasNavStatus bool
+}
+
+// decodeMessage dispatches on the AIS message type (the first 6 bits of
+// every payload) and unpacks the fields this package cares about. Message
+// types this package does not yet understand return an error so the caller
+// can skip them without treating them as fatal.
+func decodeMessage(bs *bitstream) (report, error) {
+	kind := bs.uint64(0, 6)
+	mmsi := int64(bs.uint64(8, 30))
+
+	switch kind {
+	case 1, 2, 3:
+		return report{
+			Kind:         kind,
+			MMSI:         mmsi,
+			NavStatus:    int64(bs.uint64(38, 4)),
+			HasNavStatus: true,
+			Lon:          signedFixed(bs, 61, 28, 600000.0),
+			Lat:          signedFixed(bs, 89, 27, 600000.0),
+			COG:          float64(bs.uint64(116, 12)) / 10.0,
+			SOG:          float64(bs.uint64(50, 10)) / 10.0,
+			Heading:      int64(bs.uint64(128, 9)),
+			HasKinematic: true,
+		}, nil
+	case 18, 19:
+		return report{
+			Kind:         kind,
+			MMSI:         mmsi,
+			SOG:          float64(bs.uint64(46, 10)) / 10.0,
+			Lon:          signedFixed(bs, 57, 28, 600000.0),
+			Lat:          signedFixed(bs, 85, 27, 600000.0),
+			COG:          float64(bs.uint64(112, 12)) / 10.0,
+			Heading:      int64(bs.uint64(124, 9)),
+			HasKinematic: true,
+		}, nil
+	case 5:
+		return report{
+			Kind:       kind,
+			MMSI:       mmsi,
+			IMO:        int64(bs.uint64(40, 30)),
+			CallSign:   bs.string(70, 42),
+			VesselName: bs.string(112, 120),
+			VesselType: int64(bs.uint64(232, 8)),
+			Length:     int64(bs.uint64(240, 9) + bs.uint64(249, 9)),
+			Width:      int64(bs.uint64(258, 6) + bs.uint64(264, 6)),
+			Draft:      float64(bs.uint64(294, 8)) / 10.0,
+			HasStatic:  true,
+		}, nil
+	case 24:
+		partNo := bs.uint64(38, 2)
+		r := report{Kind: kind, MMSI: mmsi, HasStatic: true}
+		switch partNo {
+		case 0:
+			r.VesselName = bs.string(40, 120)
+		case 1:
+			r.VesselType = int64(bs.uint64(40, 8))
+			r.CallSign = bs.string(90, 42)
+			r.Length = int64(bs.uint64(132, 9) + bs.uint64(141, 9))
+			r.Width = int64(bs.uint64(150, 6) + bs.uint64(156, 6))
+		default:
+			return report{}, fmt.Errorf("nmea: decode message: type 24 has no part %d", partNo)
+		}
+		return r, nil
+	default:
+		return report{}, fmt.Errorf("nmea: decode message: unsupported message type %d", kind)
+	}
+}
+
+// signedFixed reads an n-bit two's complement field starting at start and
+// scales it by 1/scale, matching the 1/10000 minute fixed-point encoding
+// AIS uses for latitude and longitude.
+func signedFixed(bs *bitstream, start, n int, scale float64) float64 {
+	return float64(bs.int64(start, n)) / scale
+}
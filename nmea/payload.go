@@ -0,0 +1,74 @@
+package nmea
+
+import "fmt"
+
+// bitstream is an unpacked sequence of bits decoded from a 6-bit ASCII
+// armored AIS payload, read most-significant-bit first to match the ITU-R
+// M.1371 wire encoding used by AIVDM/AIVDO messages.
+type bitstream struct {
+	bits []bool
+}
+
+// decodePayload converts the 6-bit ASCII armored payload characters into a
+// bitstream, dropping the fillBits padding bits from the end of the last
+// character as described in the IEC 61162 AIVDM specification.
+func decodePayload(payload string, fillBits int) (*bitstream, error) {
+	bs := &bitstream{bits: make([]bool, 0, len(payload)*6)}
+	for _, c := range payload {
+		v := int(c) - 48
+		if v > 40 {
+			v -= 8
+		}
+		if v < 0 || v > 63 {
+			return nil, fmt.Errorf("nmea: decode payload: %q is not a valid 6-bit ASCII character", c)
+		}
+		for shift := 5; shift >= 0; shift-- {
+			bs.bits = append(bs.bits, v&(1<<uint(shift)) != 0)
+		}
+	}
+	if fillBits > 0 && fillBits <= len(bs.bits) {
+		bs.bits = bs.bits[:len(bs.bits)-fillBits]
+	}
+	return bs, nil
+}
+
+// uint64 reads n bits starting at bit offset start and returns them as an
+// unsigned integer, most significant bit first.
+func (bs *bitstream) uint64(start, n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if idx := start + i; idx < len(bs.bits) && bs.bits[idx] {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// int64 reads n bits as a two's complement signed integer.
+func (bs *bitstream) int64(start, n int) int64 {
+	v := bs.uint64(start, n)
+	if v&(1<<uint(n-1)) != 0 {
+		v -= 1 << uint(n)
+	}
+	return int64(v)
+}
+
+// sixBitASCII is the character table used to decode AIS's packed string
+// fields (vessel name, call sign) per ITU-R M.1371 Table 47.
+const sixBitASCII = "@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_ !\"#$%&'()*+,-./0123456789:;<=>?"
+
+// string reads n bits starting at start as a sequence of 6-bit characters
+// and returns the decoded string with trailing '@' padding trimmed.
+func (bs *bitstream) string(start, n int) string {
+	var sb []byte
+	for i := 0; i+6 <= n; i += 6 {
+		v := bs.uint64(start+i, 6)
+		sb = append(sb, sixBitASCII[v])
+	}
+	s := string(sb)
+	for len(s) > 0 && (s[len(s)-1] == '@' || s[len(s)-1] == ' ') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+// Package nmea ingests live AIVDM/AIVDO NMEA 0183 sentences from a TCP feed
+// (or any io.Reader) and turns them into github.com/giesberge/ais Records so
+// that a live feed can be treated the same as a MarineCadastre-style CSV
+// RecordSet.
+package nmea
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sentence is a single parsed AIVDM/AIVDO line, before its fragments (if
+// any) have been reassembled into a complete payload.
+type Sentence struct {
+	Talker      string // e.g. "AI" in "!AIVDM"
+	Type        string // "VDM" or "VDO"
+	FragCount   int    // total number of fragments for this message
+	FragNum     int    // this fragment's 1-based position
+	GroupID     string // sequential message id used to match fragments together, "" if absent
+	Channel     string // AIS channel, "A" or "B"
+	Payload     string // 6-bit ASCII armored payload for this fragment
+	FillBits    int    // number of padding bits in the last 6-bit character
+	Checksum    string // two hex characters read from the sentence
+	rawChecksum byte   // checksum computed while scanning the sentence body
+}
+
+// NewSentenceScanner returns a *bufio.Scanner configured to split r into
+// individual NMEA lines so that ParseSentence can be called on each one.
+func NewSentenceScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 64*1024)
+	return s
+}
+
+// ParseSentence parses a single raw "!AIVDM,..." or "!AIVDO,..." line,
+// validates its *XX checksum, and returns the decoded Sentence.
+func ParseSentence(line string) (Sentence, error) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || (line[0] != '!' && line[0] != '$') {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: %q does not start with '!' or '$'", line)
+	}
+
+	body, checksum, err := splitChecksum(line)
+	if err != nil {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: %v", err)
+	}
+	if err := verifyChecksum(body, checksum); err != nil {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: %v", err)
+	}
+	// verifyChecksum has confirmed the computed checksum equals this value.
+	sum, _ := strconv.ParseUint(checksum, 16, 8)
+
+	fields := strings.Split(body[1:], ",")
+	if len(fields) < 6 {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: expected at least 6 comma-delimited fields, got %d", len(fields))
+	}
+	if len(fields[0]) != 5 {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: malformed talker/type %q", fields[0])
+	}
+
+	fragCount, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: fragment count: %v", err)
+	}
+	fragNum, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Sentence{}, fmt.Errorf("nmea: parse sentence: fragment number: %v", err)
+	}
+
+	fillBits := 0
+	if last := fields[len(fields)-1]; len(last) > 0 {
+		fillBits, err = strconv.Atoi(last[:1])
+		if err != nil {
+			return Sentence{}, fmt.Errorf("nmea: parse sentence: fill bits: %v", err)
+		}
+	}
+
+	return Sentence{
+		Talker:      fields[0][0:2],
+		Type:        fields[0][2:5],
+		FragCount:   fragCount,
+		FragNum:     fragNum,
+		GroupID:     fields[3],
+		Channel:     fields[4],
+		Payload:     fields[5],
+		FillBits:    fillBits,
+		Checksum:    checksum,
+		rawChecksum: byte(sum),
+	}, nil
+}
+
+// splitChecksum separates the "!AIVDM,..." body from the trailing "*XX"
+// checksum, returning an error if the checksum delimiter is missing.
+func splitChecksum(line string) (body, checksum string, err error) {
+	i := strings.LastIndexByte(line, '*')
+	if i < 0 || i != len(line)-3 {
+		return "", "", fmt.Errorf("missing *XX checksum in %q", line)
+	}
+	return line[:i], line[i+1:], nil
+}
+
+// verifyChecksum recomputes the XOR checksum of body (excluding the leading
+// '!' or '$') and compares it against the two hex characters in checksum.
+func verifyChecksum(body, checksum string) error {
+	var sum byte
+	for i := 1; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	want, err := strconv.ParseUint(checksum, 16, 8)
+	if err != nil {
+		return fmt.Errorf("invalid checksum %q: %v", checksum, err)
+	}
+	if byte(want) != sum {
+		return fmt.Errorf("checksum mismatch: sentence says %02X, computed %02X", want, sum)
+	}
+	return nil
+}
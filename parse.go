@@ -0,0 +1,182 @@
+package ais
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Correlation reports, for each Report field Record.Parse populated, which
+// Headers name it used to do so. This matters because datasets disagree on
+// names for the same field - MarineCadastre uses "BaseDateTime" where
+// AISHub uses "TIMESTAMP" - so two Records with different Headers can
+// still both produce the same Report field from different columns.
+type Correlation struct {
+	// Fields maps a Report field name (e.g. "Timestamp") to the Headers
+	// name that was matched for it (e.g. "BaseDateTime").
+	Fields map[string]string
+}
+
+// reportFieldPlan is the resolved column, for one Headers value, of a
+// single Report field.
+type reportFieldPlan struct {
+	reportFieldIndex int    // index into reflect.TypeOf(Report{}).Field(i)
+	headerIndex      int    // column index within a matching Headers
+	headerName       string // the alias from the field's `ais` tag that matched
+	required         bool
+}
+
+// parsePlan is the full set of reportFieldPlans resolved for one Headers
+// value, cached by Headers.Hash so repeated Record.Parse calls against the
+// same Headers do not re-walk Report's reflect.Type every time.
+type parsePlan struct {
+	fields []reportFieldPlan
+}
+
+// requiredReportFields lists the Report fields a Record must be able to
+// populate; all others are best-effort and left at their zero value when
+// no alias in their `ais` tag matches h.
+var requiredReportFields = map[string]bool{
+	"MMSI":      true,
+	"Timestamp": true,
+	"Lat":       true,
+	"Lon":       true,
+}
+
+var (
+	parsePlanMu    sync.Mutex
+	parsePlanCache = make(map[uint64]*parsePlan)
+)
+
+// planFor returns the parsePlan for h, building and caching it on the
+// first call for a given Headers.Hash.
+func planFor(h Headers) (*parsePlan, error) {
+	hash := h.Hash()
+
+	parsePlanMu.Lock()
+	if p, ok := parsePlanCache[hash]; ok {
+		parsePlanMu.Unlock()
+		return p, nil
+	}
+	parsePlanMu.Unlock()
+
+	p, err := buildParsePlan(h)
+	if err != nil {
+		return nil, err
+	}
+
+	parsePlanMu.Lock()
+	parsePlanCache[hash] = p
+	parsePlanMu.Unlock()
+	return p, nil
+}
+
+// buildParsePlan walks the Report struct's fields, reads each one's `ais`
+// tag as a "|"-delimited list of acceptable Headers aliases in preference
+// order, and resolves the first alias present in h for each field.
+func buildParsePlan(h Headers) (*parsePlan, error) {
+	t := reflect.TypeOf(Report{})
+	p := &parsePlan{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("ais")
+		if tag == "" {
+			continue // e.g. the unexported `data` field
+		}
+
+		headerIndex, headerName, ok := resolveAlias(h, tag)
+		if !ok {
+			if requiredReportFields[f.Name] {
+				return nil, fmt.Errorf("record parse: headers does not contain any of %q for required field %s", tag, f.Name)
+			}
+			continue
+		}
+
+		p.fields = append(p.fields, reportFieldPlan{
+			reportFieldIndex: i,
+			headerIndex:      headerIndex,
+			headerName:       headerName,
+			required:         requiredReportFields[f.Name],
+		})
+	}
+
+	return p, nil
+}
+
+// resolveAlias splits a tag of the form "BaseDateTime|TIMESTAMP|TIME" and
+// returns the column index and name of the first alias present in h.
+func resolveAlias(h Headers, tag string) (index int, name string, ok bool) {
+	start := 0
+	for i := 0; i <= len(tag); i++ {
+		if i == len(tag) || tag[i] == '|' {
+			alias := tag[start:i]
+			if idx, present := h.Contains(alias); present {
+				return idx, alias, true
+			}
+			start = i + 1
+		}
+	}
+	return 0, "", false
+}
+
+// Parse converts r into an ais.Report using h to identify which column of
+// r backs each of Report's tagged fields, and returns a Correlation
+// recording which Headers name was chosen for each field. Report fields
+// whose `ais` tag names a required field (MMSI, Timestamp, Lat, Lon) cause
+// an error if h contains none of their aliases; all other fields are left
+// at their zero value when absent.
+func (r Record) Parse(h Headers) (Report, Correlation, error) {
+	plan, err := planFor(h)
+	if err != nil {
+		return Report{}, Correlation{}, fmt.Errorf("record parse: %v", err)
+	}
+
+	var rep Report
+	repVal := reflect.ValueOf(&rep).Elem()
+	corr := Correlation{Fields: make(map[string]string, len(plan.fields))}
+
+	for _, fp := range plan.fields {
+		field := repVal.Field(fp.reportFieldIndex)
+		fieldName := repVal.Type().Field(fp.reportFieldIndex).Name
+
+		switch field.Kind() {
+		case reflect.Int64:
+			v, err := r.ParseInt(fp.headerIndex)
+			if err != nil {
+				if fp.required {
+					return Report{}, Correlation{}, fmt.Errorf("record parse: unable to parse %s from %s: %v", fieldName, fp.headerName, err)
+				}
+				continue
+			}
+			field.SetInt(v)
+		case reflect.Float64:
+			v, err := r.ParseFloat(fp.headerIndex)
+			if err != nil {
+				if fp.required {
+					return Report{}, Correlation{}, fmt.Errorf("record parse: unable to parse %s from %s: %v", fieldName, fp.headerName, err)
+				}
+				continue
+			}
+			field.SetFloat(v)
+		case reflect.String:
+			field.SetString(r[fp.headerIndex])
+		default:
+			if field.Type() == reflect.TypeOf(time.Time{}) {
+				v, err := r.ParseTime(fp.headerIndex)
+				if err != nil {
+					if fp.required {
+						return Report{}, Correlation{}, fmt.Errorf("record parse: unable to parse %s from %s: %v", fieldName, fp.headerName, err)
+					}
+					continue
+				}
+				field.Set(reflect.ValueOf(v))
+			}
+		}
+
+		corr.Fields[fieldName] = fp.headerName
+	}
+
+	return rep, corr, nil
+}
@@ -0,0 +1,256 @@
+//go:build linux || darwin
+
+package ais
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// spatialIndexPrecision is the number of bits of geohash precision used to
+// bucket records in a SpatialIndex. It matches the precision Geohasher
+// already uses for the "geohash" Field, about .1 degree of lat/lon.
+const spatialIndexPrecision = 22
+
+// spatialIndexMagic identifies a SpatialIndex file on disk so BuildSpatialIndex
+// does not need to reload a file written by an incompatible version of this
+// package.
+const spatialIndexMagic = "AISSIDX1"
+
+// spatialIndexHeaderSize is the size in bytes of the fixed header written
+// before a SpatialIndex's sorted entries: magic, source file size, source
+// file mod time (unix nanoseconds).
+const spatialIndexHeaderSize = len(spatialIndexMagic) + 8 + 8
+
+// spatialIndexEntrySize is the size in bytes of a single (geohash,
+// record-offset) entry in a SpatialIndex file.
+const spatialIndexEntrySize = 16
+
+// SpatialIndex is a sorted, memory-mapped (geohash, record-offset) index
+// built once from a RecordSet's underlying CSV file. It buckets records by
+// a fixed-precision Geohasher prefix and keeps each record's byte offset
+// within the CSV file. RecordSet.SubsetBox does not consult it: SubsetBox
+// runs the same linear Box.Match scan over every record as Subset.
+//
+// Because the index is memory-mapped rather than loaded into a Go slice,
+// it can be built once and then reused, unchanged, across every process
+// that opens the same CSV file - the same reasoning maxminddb-golang uses
+// to mmap its lookup trie instead of parsing it per process.
+type SpatialIndex struct {
+	path      string
+	data      []byte // mmap'd file contents
+	entries   int    // number of (hash, offset) entries after the header
+	sourceCSV string
+}
+
+// hashAt returns entry i's geohash, read from s.data.
+func (s *SpatialIndex) hashAt(i int) uint64 {
+	return binary.LittleEndian.Uint64(s.data[spatialIndexHeaderSize+i*spatialIndexEntrySize:])
+}
+
+// offsetAt returns entry i's record byte offset within the source CSV.
+func (s *SpatialIndex) offsetAt(i int) int64 {
+	return int64(binary.LittleEndian.Uint64(s.data[spatialIndexHeaderSize+i*spatialIndexEntrySize+8:]))
+}
+
+// Close unmaps the index's memory-mapped file. It is the caller's
+// responsibility to call Close when finished with a SpatialIndex.
+func (s *SpatialIndex) Close() error {
+	if s.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.data)
+	s.data = nil
+	if err != nil {
+		return fmt.Errorf("spatial index: close: %v", err)
+	}
+	return nil
+}
+
+// indexPathFor returns the on-disk path of the SpatialIndex for a CSV file,
+// stored alongside it with a .sidx suffix.
+func indexPathFor(csvPath string) string {
+	return csvPath + ".sidx"
+}
+
+// BuildSpatialIndex builds (or, if an up to date one already exists on
+// disk, reopens) a SpatialIndex for rs. It requires rs to have been opened
+// from a file via OpenRecordSet, since the index is keyed by byte offset
+// into that file.
+func (rs *RecordSet) BuildSpatialIndex() (*SpatialIndex, error) {
+	f, ok := rs.data.(*os.File)
+	if !ok {
+		return nil, fmt.Errorf("build spatial index: recordset was not opened with OpenRecordSet")
+	}
+	csvPath := f.Name()
+
+	latIdx, ok := rs.Headers().Contains("LAT")
+	if !ok {
+		return nil, fmt.Errorf("build spatial index: headers does not contain LAT")
+	}
+	lonIdx, ok := rs.Headers().Contains("LON")
+	if !ok {
+		return nil, fmt.Errorf("build spatial index: headers does not contain LON")
+	}
+
+	info, err := os.Stat(csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("build spatial index: %v", err)
+	}
+
+	if idx, err := openSpatialIndex(csvPath, info); err == nil {
+		return idx, nil
+	}
+
+	if err := buildSpatialIndexFile(csvPath, info, latIdx, lonIdx); err != nil {
+		return nil, fmt.Errorf("build spatial index: %v", err)
+	}
+	return openSpatialIndex(csvPath, info)
+}
+
+// openSpatialIndex mmaps the on-disk index for csvPath, returning an error
+// if it does not exist or is stale relative to info (the current stat of
+// the source CSV). A stale or missing index is the expected, graceful-
+// degradation path: callers fall back to building a fresh one, or to a
+// linear scan, rather than treating this as fatal.
+func openSpatialIndex(csvPath string, info os.FileInfo) (*SpatialIndex, error) {
+	idxPath := indexPathFor(csvPath)
+	f, err := os.Open(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idxInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if idxInfo.Size() < int64(spatialIndexHeaderSize) {
+		return nil, fmt.Errorf("spatial index: %s is truncated", idxPath)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(idxInfo.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("spatial index: mmap: %v", err)
+	}
+
+	if string(data[:len(spatialIndexMagic)]) != spatialIndexMagic {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("spatial index: %s has an unrecognized header", idxPath)
+	}
+	wantSize := binary.LittleEndian.Uint64(data[len(spatialIndexMagic):])
+	wantModTime := binary.LittleEndian.Uint64(data[len(spatialIndexMagic)+8:])
+	if int64(wantSize) != info.Size() || int64(wantModTime) != info.ModTime().UnixNano() {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("spatial index: %s is stale relative to %s", idxPath, csvPath)
+	}
+
+	entries := (len(data) - spatialIndexHeaderSize) / spatialIndexEntrySize
+	return &SpatialIndex{path: idxPath, data: data, entries: entries, sourceCSV: csvPath}, nil
+}
+
+// buildSpatialIndexFile scans csvPath line by line, computing each
+// record's byte offset and geohash, and writes the sorted result to
+// indexPathFor(csvPath).
+func buildSpatialIndexFile(csvPath string, info os.FileInfo, latIdx, lonIdx int) error {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type entry struct {
+		hash   uint64
+		offset int64
+	}
+	var entries []entry
+
+	r := bufio.NewReader(f)
+	var offset int64
+
+	// The header line is not a record; skip it but still account for its
+	// bytes so subsequent offsets are correct.
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read header: %v", err)
+	}
+	offset += int64(len(header))
+
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			break
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed != "" && trimmed[0] != '#' {
+			fields := strings.Split(trimmed, ",")
+			if latIdx < len(fields) && lonIdx < len(fields) {
+				lat, latErr := strconv.ParseFloat(fields[latIdx], 64)
+				lon, lonErr := strconv.ParseFloat(fields[lonIdx], 64)
+				if latErr == nil && lonErr == nil {
+					hash := geohash.EncodeIntWithPrecision(lat, lon, uint(spatialIndexPrecision))
+					entries = append(entries, entry{hash: hash, offset: offset})
+				}
+			}
+		}
+		offset += int64(len(line))
+		if err != nil {
+			break
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+
+	out, err := os.Create(indexPathFor(csvPath))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	w.WriteString(spatialIndexMagic)
+	var sizeBuf, modBuf [8]byte
+	binary.LittleEndian.PutUint64(sizeBuf[:], uint64(info.Size()))
+	binary.LittleEndian.PutUint64(modBuf[:], uint64(info.ModTime().UnixNano()))
+	w.Write(sizeBuf[:])
+	w.Write(modBuf[:])
+
+	var entryBuf [spatialIndexEntrySize]byte
+	for _, e := range entries {
+		binary.LittleEndian.PutUint64(entryBuf[0:8], e.hash)
+		binary.LittleEndian.PutUint64(entryBuf[8:16], uint64(e.offset))
+		if _, err := w.Write(entryBuf[:]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// SubsetBox returns a pointer to a new *RecordSet containing the records in
+// rs that fall within b.
+//
+// SubsetBox used to narrow its search with a SpatialIndex, scanning only
+// the byte range between the geohashes of b's two corners before the exact
+// lat/lon test. That range is not a valid bound: a geohash interleaves
+// latitude and longitude bits into a single Z-order-curve value, so a
+// corner-to-corner span can fail to cover every cell inside b when b
+// straddles the equator or the antimeridian, and geohash.EncodeIntWithPrecision
+// itself overflows at the coordinate extremes (e.g. (90, 180) wraps to 0),
+// which can leave maxHash smaller than the records it should bound. Both
+// turn into silently missing in-box records rather than a loud failure.
+// Until SubsetBox computes a real Z-order cover - a BIGMIN/LITMAX range
+// decomposition, or the set of geohash-prefix buckets that tile b - it
+// falls back to the same linear Box.Match scan as Subset. A SpatialIndex
+// can still be built with BuildSpatialIndex for future use; SubsetBox just
+// does not trust it for correctness yet.
+func (rs *RecordSet) SubsetBox(b Box) (*RecordSet, error) {
+	return rs.Subset(&b)
+}
@@ -0,0 +1,179 @@
+//go:build linux || darwin
+
+package ais
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildSpatialFixture writes an n-record CSV file (MMSI, LAT, LON headers)
+// to a fresh temp directory and returns its path. Records are spread evenly
+// in latitude from -90 to 90 and cycle through 10 MMSIs, so a caller
+// querying the northern hemisphere (MinLat: 0) gets a record count and
+// vessel count it can compute in advance.
+func buildSpatialFixture(n int) (path string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "ais-spatial-fixture")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	path = filepath.Join(dir, "fixture.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString("MMSI,LAT,LON\n")
+	for i := 0; i < n; i++ {
+		lat := -90 + float64(i)*180.0/float64(n-1)
+		fmt.Fprintf(w, "%d,%g,0\n", i%10, lat)
+	}
+	if err := w.Flush(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// northernHemisphereBox is the query both BenchmarkSubsetBox and
+// Example_recordSet_SubsetBox use: every record buildSpatialFixture writes
+// with LAT >= 0.
+var northernHemisphereBox = Box{MinLat: 0, MaxLat: 90, MinLon: -180, MaxLon: 180, LatIndex: 1, LonIndex: 2}
+
+// recordCount returns the number of records rs holds, consuming rs's
+// underlying reader.
+func recordCount(rs *RecordSet) (int, error) {
+	n := 0
+	for {
+		_, err := rs.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
+
+// BenchmarkSubsetBox measures SubsetBox against a 1M-record fixture. Before
+// timing, it asserts SubsetBox's match count equals the linear Subset's, so
+// a regression that silently drops in-box records (as the corner-to-corner
+// geohash range bug once did) fails the benchmark instead of just timing an
+// empty query.
+func BenchmarkSubsetBox(b *testing.B) {
+	path, cleanup, err := buildSpatialFixture(1_000_000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cleanup()
+
+	linearRS, err := OpenRecordSet(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	linearSubset, err := linearRS.Subset(&northernHemisphereBox)
+	if err != nil {
+		b.Fatal(err)
+	}
+	wantCount, err := recordCount(linearSubset)
+	if err != nil {
+		b.Fatal(err)
+	}
+	linearRS.Close()
+	if wantCount == 0 {
+		b.Fatal("linear Subset matched no records against northernHemisphereBox; fixture is broken")
+	}
+
+	rs, err := OpenRecordSet(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	gotSubset, err := rs.SubsetBox(northernHemisphereBox)
+	if err != nil {
+		b.Fatal(err)
+	}
+	gotCount, err := recordCount(gotSubset)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rs.Close()
+	if gotCount != wantCount {
+		b.Fatalf("SubsetBox matched %d records, linear Subset matched %d", gotCount, wantCount)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs, err := OpenRecordSet(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := rs.SubsetBox(northernHemisphereBox); err != nil {
+			b.Fatal(err)
+		}
+		rs.Close()
+	}
+}
+
+// BenchmarkSubsetLinear measures the same query against the same fixture
+// via the linear-scan Subset, as a baseline for BenchmarkSubsetBox.
+func BenchmarkSubsetLinear(b *testing.B) {
+	path, cleanup, err := buildSpatialFixture(1_000_000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cleanup()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs, err := OpenRecordSet(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := rs.Subset(&northernHemisphereBox); err != nil {
+			b.Fatal(err)
+		}
+		rs.Close()
+	}
+}
+
+// Example_recordSet_SubsetBox narrows a RecordSet to the northern hemisphere
+// with SubsetBox, then counts the distinct vessels in that subset with
+// UniqueVessels. buildSpatialFixture spreads 2000 records evenly from -90 to
+// 90 degrees latitude across 10 MMSIs, so exactly the upper half (1000
+// records, all 10 MMSIs) fall within the box.
+func Example_recordSet_SubsetBox() {
+	path, cleanup, err := buildSpatialFixture(2000)
+	if err != nil {
+		panic(err)
+	}
+	defer cleanup()
+
+	rs, err := OpenRecordSet(path)
+	if err != nil {
+		panic(err)
+	}
+	defer rs.Close()
+
+	subset, err := rs.SubsetBox(northernHemisphereBox)
+	if err != nil {
+		panic(err)
+	}
+	defer subset.Close()
+
+	vessels, err := subset.UniqueVessels()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(len(vessels))
+	// Output: 10
+}
@@ -0,0 +1,170 @@
+package ais
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeLayouts are, in preference order, the timestamp formats seen
+// in public AIS datasets: MarineCadastre's own TimeLayout, a
+// space-delimited variant of it, RFC3339 (AISHub), a U.S.-style
+// slash-delimited date/time (Danish DMA exports a close variant), and a
+// year-less syslog-style layout where the current year is filled in
+// separately since time.Parse cannot do it for us.
+var defaultTimeLayouts = []string{
+	TimeLayout,
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"01/02/2006 15:04:05",
+	"Jan _2 15:04:05",
+}
+
+// DefaultTimeParser is the package-level TimeParser used by Record.ParseTime.
+// It is safe for concurrent use.
+var DefaultTimeParser = NewTimeParser(defaultTimeLayouts...)
+
+// maxCachedLayoutsPerColumn bounds how many distinct layouts Parse
+// remembers for a single column index. A DefaultTimeParser is shared
+// across every Record.ParseTime call process-wide, so two datasets whose
+// timestamp columns happen to land on the same index but use different
+// layouts would otherwise repeatedly evict each other's single cached
+// entry; remembering a handful lets both survive.
+const maxCachedLayoutsPerColumn = 4
+
+// TimeParser holds an ordered list of candidate time.Parse layouts, plus
+// Unix-seconds and Unix-milliseconds numeric parsing, and tries each in
+// turn. Once a layout succeeds for a given Headers column, it is moved to
+// the front of that column's small recency-ordered cache so later rows for
+// the same column - or for a different dataset sharing the column index -
+// try their own winning layout first instead of re-trying every candidate.
+type TimeParser struct {
+	layouts []string
+
+	mu    sync.Mutex
+	cache map[int][]string // column index -> recently winning layouts, most recent first; "" means numeric Unix time won
+}
+
+// NewTimeParser returns a *TimeParser that tries layouts, in order, before
+// falling back to parsing the value as Unix seconds or milliseconds.
+func NewTimeParser(layouts ...string) *TimeParser {
+	return &TimeParser{
+		layouts: layouts,
+		cache:   make(map[int][]string),
+	}
+}
+
+// Parse parses s as a time.Time, trying column's recently cached layouts
+// (if any) before every candidate layout, then numeric Unix seconds and
+// milliseconds. On success, the winning layout is promoted to the front of
+// column's cache.
+func (p *TimeParser) Parse(column int, s string) (time.Time, error) {
+	p.mu.Lock()
+	cached := append([]string(nil), p.cache[column]...)
+	p.mu.Unlock()
+
+	for _, layout := range cached {
+		t, ok := time.Time{}, false
+		if layout == "" {
+			t, ok = p.tryUnix(s)
+		} else {
+			t, ok = p.tryLayout(layout, s)
+		}
+		if ok {
+			p.promote(column, layout)
+			return t, nil
+		}
+	}
+
+	for _, layout := range p.layouts {
+		if containsString(cached, layout) {
+			continue // already tried above
+		}
+		if t, ok := p.tryLayout(layout, s); ok {
+			p.promote(column, layout)
+			return t, nil
+		}
+	}
+
+	if t, ok := p.tryUnix(s); ok {
+		p.promote(column, "")
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("timeparser: unable to parse %q against any known layout", s)
+}
+
+// promote moves layout to the front of column's recency-ordered cache,
+// trimming it to maxCachedLayoutsPerColumn entries.
+func (p *TimeParser) promote(column int, layout string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recent := p.cache[column]
+	kept := make([]string, 0, maxCachedLayoutsPerColumn)
+	kept = append(kept, layout)
+	for _, l := range recent {
+		if l == layout {
+			continue
+		}
+		if len(kept) >= maxCachedLayoutsPerColumn {
+			break
+		}
+		kept = append(kept, l)
+	}
+	p.cache[column] = kept
+}
+
+// containsString reports whether s is present in list.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tryLayout attempts time.Parse with layout, filling in the current year
+// when the layout does not itself carry one (e.g. "Jan _2 15:04:05").
+func (p *TimeParser) tryLayout(layout, s string) (time.Time, bool) {
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if t.Year() == 0 {
+		t = t.AddDate(time.Now().Year(), 0, 0)
+	}
+	return t, true
+}
+
+// tryUnix attempts to parse s as a base-10 integer number of Unix seconds
+// or, if that value looks too large to be seconds, milliseconds.
+func (p *TimeParser) tryUnix(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// A seconds-resolution Unix timestamp for any date in, say, the last
+	// century or next century fits well under 10^12; anything at or above
+	// that is almost certainly milliseconds.
+	const millisecondThreshold = 1e12
+	if n >= millisecondThreshold || n <= -millisecondThreshold {
+		return time.UnixMilli(n).UTC(), true
+	}
+	return time.Unix(n, 0).UTC(), true
+}
+
+// ParseTimeWith parses the index value of a field in the Record as a
+// time.Time using p instead of the package's hard-coded TimeLayout,
+// auto-detecting the source format among p's candidate layouts.
+func (r Record) ParseTimeWith(index int, p *TimeParser) (time.Time, error) {
+	t, err := p.Parse(index, r[index])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("record: parsetimewith: %v", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,233 @@
+package ais
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Track is a sorted-by-time slice of Records belonging to a single MMSI,
+// together with the Headers indices needed to read lat, lon, and
+// timestamp from each one. Build a Track from a RecordSet already
+// filtered to one vessel and sorted with SortByTime.
+type Track struct {
+	Records            []Record
+	LatIndex, LonIndex int
+	TimeIndex          int
+}
+
+// NewTrack returns a Track over recs, which must already be sorted in
+// ascending time order (SortByTime produces this order).
+func NewTrack(recs []Record, latIndex, lonIndex, timeIndex int) Track {
+	return Track{Records: recs, LatIndex: latIndex, LonIndex: lonIndex, TimeIndex: timeIndex}
+}
+
+// CumulativeDistanceNM returns the total great-circle distance, in nautical
+// miles, along the Track: the sum of Record.Distance between every
+// consecutive pair of fixes.
+func (t Track) CumulativeDistanceNM() (float64, error) {
+	var total float64
+	for i := 1; i < len(t.Records); i++ {
+		nm, err := t.Records[i-1].Distance(t.Records[i], t.LatIndex, t.LonIndex)
+		if err != nil {
+			return 0, fmt.Errorf("track: cumulative distance: %v", err)
+		}
+		total += nm
+	}
+	return total, nil
+}
+
+// AverageSpeedKn returns the Track's average speed in knots: its
+// CumulativeDistanceNM divided by the elapsed time between its first and
+// last fix.
+func (t Track) AverageSpeedKn() (float64, error) {
+	if len(t.Records) < 2 {
+		return 0, fmt.Errorf("track: average speed: need at least 2 records")
+	}
+	dist, err := t.CumulativeDistanceNM()
+	if err != nil {
+		return 0, fmt.Errorf("track: average speed: %v", err)
+	}
+	t0, err := t.Records[0].ParseTime(t.TimeIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: average speed: %v", err)
+	}
+	t1, err := t.Records[len(t.Records)-1].ParseTime(t.TimeIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: average speed: %v", err)
+	}
+	hours := t1.Sub(t0).Hours()
+	if hours <= 0 {
+		return 0, fmt.Errorf("track: average speed: non-positive elapsed time")
+	}
+	return dist / hours, nil
+}
+
+// InitialBearing returns the forward azimuth, in degrees clockwise from
+// true north, from the fix at index i to the fix at index j, using the
+// standard forward-azimuth formula:
+//
+//	atan2(sin(Δλ)·cos(φ2), cos(φ1)·sin(φ2) − sin(φ1)·cos(φ2)·cos(Δλ))
+func (t Track) InitialBearing(i, j int) (float64, error) {
+	lat1, err := t.Records[i].ParseFloat(t.LatIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: initial bearing: %v", err)
+	}
+	lon1, err := t.Records[i].ParseFloat(t.LonIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: initial bearing: %v", err)
+	}
+	lat2, err := t.Records[j].ParseFloat(t.LatIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: initial bearing: %v", err)
+	}
+	lon2, err := t.Records[j].ParseFloat(t.LonIndex)
+	if err != nil {
+		return 0, fmt.Errorf("track: initial bearing: %v", err)
+	}
+	return bearing(lat1, lon1, lat2, lon2), nil
+}
+
+// bearing computes the forward azimuth in degrees [0, 360) from (lat1,
+// lon1) to (lat2, lon2), both in decimal degrees.
+func bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := deg2rad(lat1), deg2rad(lat2)
+	deltaLambda := deg2rad(lon2 - lon1)
+
+	y := math.Sin(deltaLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(deltaLambda)
+
+	theta := math.Atan2(y, x)
+	return math.Mod(rad2deg(theta)+360, 360)
+}
+
+func deg2rad(d float64) float64 { return d * math.Pi / 180 }
+func rad2deg(r float64) float64 { return r * 180 / math.Pi }
+
+// Gap is a period between two consecutive fixes in a Track whose interval
+// exceeds the maxInterval passed to Gaps.
+type Gap struct {
+	Start, End time.Time
+	FromIndex  int // index, within Track.Records, of the fix before the gap
+}
+
+// Gaps returns every interval between consecutive fixes in t that exceeds
+// maxInterval, in ascending time order. This is useful for distinguishing
+// a genuine AIS dropout from a vessel that is simply moored and
+// transmitting infrequently.
+func (t Track) Gaps(maxInterval time.Duration) ([]Gap, error) {
+	var gaps []Gap
+	for i := 1; i < len(t.Records); i++ {
+		t0, err := t.Records[i-1].ParseTime(t.TimeIndex)
+		if err != nil {
+			return nil, fmt.Errorf("track: gaps: %v", err)
+		}
+		t1, err := t.Records[i].ParseTime(t.TimeIndex)
+		if err != nil {
+			return nil, fmt.Errorf("track: gaps: %v", err)
+		}
+		if t1.Sub(t0) > maxInterval {
+			gaps = append(gaps, Gap{Start: t0, End: t1, FromIndex: i - 1})
+		}
+	}
+	return gaps, nil
+}
+
+// Resample returns a new Track with one fix every interval, linearly
+// interpolating lat, lon, and time between the two bracketing fixes in t.
+// Longitude is unwrapped to the shortest arc across the ±180° meridian
+// before interpolating, so a track crossing it does not produce a fix that
+// swings the long way around the globe.
+func (t Track) Resample(interval time.Duration) (Track, error) {
+	if len(t.Records) < 2 {
+		return t, nil
+	}
+	if interval <= 0 {
+		return Track{}, fmt.Errorf("track: resample: interval must be positive")
+	}
+
+	start, err := t.Records[0].ParseTime(t.TimeIndex)
+	if err != nil {
+		return Track{}, fmt.Errorf("track: resample: %v", err)
+	}
+	end, err := t.Records[len(t.Records)-1].ParseTime(t.TimeIndex)
+	if err != nil {
+		return Track{}, fmt.Errorf("track: resample: %v", err)
+	}
+
+	out := Track{LatIndex: t.LatIndex, LonIndex: t.LonIndex, TimeIndex: t.TimeIndex}
+	bracket := 0
+	for at := start; !at.After(end); at = at.Add(interval) {
+		for bracket < len(t.Records)-2 {
+			next, err := t.Records[bracket+1].ParseTime(t.TimeIndex)
+			if err != nil {
+				return Track{}, fmt.Errorf("track: resample: %v", err)
+			}
+			if at.Before(next) {
+				break
+			}
+			bracket++
+		}
+
+		rec, err := t.interpolate(bracket, at)
+		if err != nil {
+			return Track{}, fmt.Errorf("track: resample: %v", err)
+		}
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
+
+// interpolate linearly interpolates a fix at time at between
+// t.Records[i] and t.Records[i+1].
+func (t Track) interpolate(i int, at time.Time) (Record, error) {
+	t0, err := t.Records[i].ParseTime(t.TimeIndex)
+	if err != nil {
+		return nil, err
+	}
+	t1, err := t.Records[i+1].ParseTime(t.TimeIndex)
+	if err != nil {
+		return nil, err
+	}
+	lat0, err := t.Records[i].ParseFloat(t.LatIndex)
+	if err != nil {
+		return nil, err
+	}
+	lat1, err := t.Records[i+1].ParseFloat(t.LatIndex)
+	if err != nil {
+		return nil, err
+	}
+	lon0, err := t.Records[i].ParseFloat(t.LonIndex)
+	if err != nil {
+		return nil, err
+	}
+	lon1, err := t.Records[i+1].ParseFloat(t.LonIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	frac := 0.0
+	if span := t1.Sub(t0); span > 0 {
+		frac = at.Sub(t0).Seconds() / span.Seconds()
+	}
+
+	// Unwrap longitude to the shortest arc across the antimeridian before
+	// interpolating, then wrap the result back into [-180, 180).
+	delta := lon1 - lon0
+	if delta > 180 {
+		delta -= 360
+	} else if delta < -180 {
+		delta += 360
+	}
+	lon := math.Mod(lon0+delta*frac+540, 360) - 180
+	lat := lat0 + (lat1-lat0)*frac
+	interpTime := t0.Add(time.Duration(frac * float64(t1.Sub(t0))))
+
+	rec := make(Record, len(t.Records[i]))
+	copy(rec, t.Records[i])
+	rec[t.LatIndex] = strconv.FormatFloat(lat, 'f', -1, 64)
+	rec[t.LonIndex] = strconv.FormatFloat(lon, 'f', -1, 64)
+	rec[t.TimeIndex] = interpTime.UTC().Format(TimeLayout)
+	return rec, nil
+}
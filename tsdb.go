@@ -0,0 +1,586 @@
+package ais
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// walFlushThreshold is the number of WAL-backed Write() calls between
+// fsyncs, matching the existing flushThreshold convention of amortizing an
+// expensive operation across many cheap appends.
+const walFlushThreshold = 1000
+
+// shardFooterPrefix marks the trailing comment line of a compacted shard
+// file. Shard files are otherwise ordinary CSV, and the csv.Reader used
+// throughout this package is configured with Comment = '#', so readers
+// that do not know about shards can still read one directly.
+const shardFooterPrefix = "#FOOTER "
+
+// RangeMatching is implemented by selection criteria that, in addition to
+// Matching, can report the time range and lat/lon bounding box they care
+// about. A tsdbStore uses MatchRange and MatchBox to skip whole shards whose
+// footer cannot overlap the request before falling back to the regular
+// Box/CSV scan for the shards that remain.
+type RangeMatching interface {
+	Matching
+	MatchRange(minTime, maxTime time.Time) bool
+	MatchBox(minLat, maxLat, minLon, maxLon float64) bool
+}
+
+// MMSIMatching is an optional extension to RangeMatching for selection
+// criteria scoped to a single vessel. When m implements MMSIMatching,
+// SubsetRange consults a shard's MMSI bloom filter and skips the shard
+// outright when MayContain reports the MMSI cannot be present.
+type MMSIMatching interface {
+	RangeMatching
+	MMSI() string
+}
+
+// TimeRangeBox pairs a Box with the time range its caller is interested in,
+// implementing RangeMatching so RecordSet.SubsetRange can prune shards by
+// time and by bounding box before falling back to Box.Match's exact lat/lon
+// test.
+type TimeRangeBox struct {
+	Box
+	TimeIndex        int
+	MinTime, MaxTime time.Time
+}
+
+// MatchRange implements RangeMatching for TimeRangeBox.
+func (t *TimeRangeBox) MatchRange(minTime, maxTime time.Time) bool {
+	return !maxTime.Before(t.MinTime) && !minTime.After(t.MaxTime)
+}
+
+// MatchBox implements RangeMatching for TimeRangeBox, reporting whether
+// t's own box overlaps [minLat, maxLat] x [minLon, maxLon] - a shard footer's
+// bounding box, in SubsetRange's case.
+func (t *TimeRangeBox) MatchBox(minLat, maxLat, minLon, maxLon float64) bool {
+	return t.MaxLat >= minLat && t.MinLat <= maxLat && t.MaxLon >= minLon && t.MinLon <= maxLon
+}
+
+// Match implements Matching for TimeRangeBox, additionally requiring the
+// record's own timestamp fall within [MinTime, MaxTime].
+func (t *TimeRangeBox) Match(rec *Record) (bool, error) {
+	ok, err := t.Box.Match(rec)
+	if err != nil || !ok {
+		return ok, err
+	}
+	ts, err := rec.ParseTime(t.TimeIndex)
+	if err != nil {
+		return false, fmt.Errorf("timerangebox: match: %v", err)
+	}
+	return !ts.Before(t.MinTime) && !ts.After(t.MaxTime), nil
+}
+
+// shardFooter is the compaction-time summary written at the end of every
+// shard file so SubsetRange can skip matching a shard's records when the
+// footer rules the shard out. SubsetRange still reads the whole shard file
+// to reach its footer.
+type shardFooter struct {
+	MinTime, MaxTime               time.Time
+	MinLat, MaxLat, MinLon, MaxLon float64
+	MMSI                           *bloomFilter
+}
+
+// tsdbStore holds the state behind a RecordSet opened with
+// OpenTSDBRecordSet: a directory containing an append-only WAL of records
+// not yet compacted, plus zero or more immutable, time-partitioned shard
+// files produced by Compact.
+type tsdbStore struct {
+	dir        string
+	wal        *os.File
+	walWriter  *bufio.Writer
+	writeCount int
+	timeIndex  int // index of BaseDateTime within the RecordSet's Headers
+	latIndex   int
+	lonIndex   int
+	shards     []string   // shard file paths under dir, in no particular order
+	shardFiles []*os.File // open handles from the most recent resetReader call
+}
+
+// OpenTSDBRecordSet opens (creating if necessary) a write-ahead-log backed
+// RecordSet rooted at dir, modeled on the InfluxDB tsdb engine: Write
+// appends to the WAL in O(1) time, and Compact periodically sorts the WAL
+// into immutable, time-partitioned shard files. It returns the same
+// *RecordSet surface as OpenRecordSet and NewRecordSet, so AppendField,
+// Subset, SortByTime, and UniqueVessels keep working against it.
+func OpenTSDBRecordSet(dir string) (*RecordSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("open tsdb recordset: %v", err)
+	}
+
+	walPath := filepath.Join(dir, "wal.log")
+	f, err := os.OpenFile(walPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("open tsdb recordset: %v", err)
+	}
+
+	rs := NewRecordSet()
+	rs.h = Headers{Fields: []string{
+		"MMSI", "BaseDateTime", "LAT", "LON", "SOG", "COG", "Heading",
+		"VesselName", "IMO", "CallSign", "VesselType", "Length", "Width",
+		"Draft", "Cargo", "Status",
+	}}
+	timeIndex, _ := rs.h.Contains("BaseDateTime")
+	latIndex, _ := rs.h.Contains("LAT")
+	lonIndex, _ := rs.h.Contains("LON")
+
+	store := &tsdbStore{
+		dir:       dir,
+		wal:       f,
+		walWriter: bufio.NewWriter(f),
+		timeIndex: timeIndex,
+		latIndex:  latIndex,
+		lonIndex:  lonIndex,
+	}
+	store.shards, err = store.listShards()
+	if err != nil {
+		return nil, fmt.Errorf("open tsdb recordset: %v", err)
+	}
+	rs.tsdb = store
+	rs.data = f
+
+	if err := rs.tsdb.resetReader(rs); err != nil {
+		return nil, fmt.Errorf("open tsdb recordset: %v", err)
+	}
+
+	return rs, nil
+}
+
+// resetReader rebuilds rs.r so that reading the RecordSet (directly via
+// Read, or through Subset/SortByTime/UniqueVessels which all read through
+// rs.r) sees every compacted shard followed by whatever is still sitting in
+// the WAL. csv.Reader's Comment = '#' (set by NewRecordSet) means shard
+// footer lines are skipped automatically, so shard files can be read as
+// plain CSV. resetReader must be called again after Compact changes which
+// records live in shards versus the WAL.
+func (s *tsdbStore) resetReader(rs *RecordSet) error {
+	s.closeShardFiles()
+
+	readers := make([]io.Reader, 0, len(s.shards)+1)
+	files := make([]*os.File, 0, len(s.shards))
+	for _, path := range s.shards {
+		f, err := os.Open(path)
+		if err != nil {
+			closeFiles(files)
+			return fmt.Errorf("tsdb: reset reader: %v", err)
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	s.shardFiles = files
+
+	if _, err := s.wal.Seek(0, 0); err != nil {
+		return fmt.Errorf("tsdb: reset reader: %v", err)
+	}
+	readers = append(readers, s.wal)
+
+	rs.r = csv.NewReader(io.MultiReader(readers...))
+	rs.r.LazyQuotes = true
+	rs.r.Comment = '#'
+	return nil
+}
+
+// closeShardFiles closes the shard file handles opened by the previous
+// resetReader call, if any, so repeated OpenTSDBRecordSet/Compact calls do
+// not leak file descriptors.
+func (s *tsdbStore) closeShardFiles() {
+	closeFiles(s.shardFiles)
+	s.shardFiles = nil
+}
+
+// closeFiles closes every file in files, ignoring errors: callers use it to
+// clean up handles that are being discarded anyway.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// listShards returns the paths of every compacted shard file under
+// store.dir, identified by the shard-<unixhour>.csv naming Compact uses.
+func (s *tsdbStore) listShards() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var shards []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "shard-") && strings.HasSuffix(e.Name(), ".csv") {
+			shards = append(shards, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	return shards, nil
+}
+
+// writeWAL appends rec to the write-ahead log. It is O(1): the record is
+// written to a buffered writer and only fsynced every walFlushThreshold
+// writes, so continuous NMEA ingest does not pay a disk sync per record.
+func (s *tsdbStore) writeWAL(rec Record) error {
+	if _, err := s.walWriter.Write(rec.Data()); err != nil {
+		return fmt.Errorf("tsdb: wal write: %v", err)
+	}
+	s.writeCount++
+	if s.writeCount%walFlushThreshold == 0 {
+		return s.flushWAL()
+	}
+	return nil
+}
+
+// close flushes and closes the WAL and the shard file handles held open by
+// the most recent resetReader call. RecordSet.Close calls this for a
+// RecordSet opened with OpenTSDBRecordSet.
+func (s *tsdbStore) close() error {
+	s.closeShardFiles()
+	if err := s.flushWAL(); err != nil {
+		return err
+	}
+	return s.wal.Close()
+}
+
+// flushWAL flushes the buffered WAL writer and fsyncs the underlying file.
+func (s *tsdbStore) flushWAL() error {
+	if err := s.walWriter.Flush(); err != nil {
+		return fmt.Errorf("tsdb: wal flush: %v", err)
+	}
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("tsdb: wal sync: %v", err)
+	}
+	return nil
+}
+
+// shardHour buckets a timestamp into its one-hour shard, truncated to the
+// top of the hour in UTC.
+func shardHour(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+// Compact flushes the WAL, sorts its records by (MMSI, BaseDateTime) into
+// one shard per hour of BaseDateTime, and writes each shard with a footer
+// of min/max time, min/max lat/lon, and an MMSI bloom filter so that
+// SubsetRange can prune whole shards without scanning them. The WAL is
+// truncated once every record has been durably written to a shard.
+func (rs *RecordSet) Compact() error {
+	s := rs.tsdb
+	if s == nil {
+		return fmt.Errorf("compact: recordset was not opened with OpenTSDBRecordSet")
+	}
+	if err := s.flushWAL(); err != nil {
+		return fmt.Errorf("compact: %v", err)
+	}
+
+	f, err := os.Open(s.wal.Name())
+	if err != nil {
+		return fmt.Errorf("compact: %v", err)
+	}
+	defer f.Close()
+
+	byHour := make(map[time.Time][]Record)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\n")
+		if line == "" {
+			continue
+		}
+		rec := Record(strings.Split(line, ","))
+		ts, err := rec.ParseTime(s.timeIndex)
+		if err != nil {
+			return fmt.Errorf("compact: parse time: %v", err)
+		}
+		hour := shardHour(ts)
+		byHour[hour] = append(byHour[hour], rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("compact: %v", err)
+	}
+
+	for hour, recs := range byHour {
+		if err := s.writeShard(hour, recs); err != nil {
+			return fmt.Errorf("compact: %v", err)
+		}
+	}
+
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("compact: truncate wal: %v", err)
+	}
+	if _, err := s.wal.Seek(0, 0); err != nil {
+		return fmt.Errorf("compact: seek wal: %v", err)
+	}
+	s.shards, err = s.listShards()
+	if err != nil {
+		return fmt.Errorf("compact: %v", err)
+	}
+	return s.resetReader(rs)
+}
+
+// writeShard sorts recs by (MMSI, BaseDateTime), appends them to the
+// existing shard file for hour (if any), and rewrites the shard's footer.
+func (s *tsdbStore) writeShard(hour time.Time, recs []Record) error {
+	path := filepath.Join(s.dir, fmt.Sprintf("shard-%d.csv", hour.Unix()))
+
+	existing, footer, err := readShard(path, s.timeIndex, s.latIndex, s.lonIndex)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	recs = append(existing, recs...)
+
+	sort.Slice(recs, func(i, j int) bool {
+		mi, _ := recs[i].ParseInt(0) // MMSI is always column 0 in this schema
+		mj, _ := recs[j].ParseInt(0)
+		if mi != mj {
+			return mi < mj
+		}
+		ti, _ := recs[i].ParseTime(s.timeIndex)
+		tj, _ := recs[j].ParseTime(s.timeIndex)
+		return ti.Before(tj)
+	})
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	footer = shardFooter{MMSI: newBloomFilter(1024)}
+	for i, rec := range recs {
+		if _, err := w.Write(rec.Data()); err != nil {
+			return err
+		}
+		ts, _ := rec.ParseTime(s.timeIndex)
+		lat, _ := rec.ParseFloat(s.latIndex)
+		lon, _ := rec.ParseFloat(s.lonIndex)
+		mmsi := rec[0]
+		footer.MMSI.Add(mmsi)
+		if i == 0 {
+			footer.MinTime, footer.MaxTime = ts, ts
+			footer.MinLat, footer.MaxLat = lat, lat
+			footer.MinLon, footer.MaxLon = lon, lon
+			continue
+		}
+		if ts.Before(footer.MinTime) {
+			footer.MinTime = ts
+		}
+		if ts.After(footer.MaxTime) {
+			footer.MaxTime = ts
+		}
+		if lat < footer.MinLat {
+			footer.MinLat = lat
+		}
+		if lat > footer.MaxLat {
+			footer.MaxLat = lat
+		}
+		if lon < footer.MinLon {
+			footer.MinLon = lon
+		}
+		if lon > footer.MaxLon {
+			footer.MaxLon = lon
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s%s\n", shardFooterPrefix, footer.encode()); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readShard reads the data rows and footer (if present) of an existing
+// shard file. It returns os.ErrNotExist when path does not exist yet.
+func readShard(path string, timeIndex, latIndex, lonIndex int) ([]Record, shardFooter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, shardFooter{}, err
+	}
+	defer f.Close()
+
+	var recs []Record
+	var footer shardFooter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, shardFooterPrefix) {
+			footer, err = decodeShardFooter(strings.TrimPrefix(line, shardFooterPrefix))
+			if err != nil {
+				return nil, shardFooter{}, fmt.Errorf("read shard: %v", err)
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		recs = append(recs, Record(strings.Split(line, ",")))
+	}
+	return recs, footer, scanner.Err()
+}
+
+// encode serializes a shardFooter into the single-line, comma-delimited
+// format written after a shard's data rows.
+func (f shardFooter) encode() string {
+	return strings.Join([]string{
+		f.MinTime.UTC().Format(time.RFC3339),
+		f.MaxTime.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(f.MinLat, 'f', -1, 64),
+		strconv.FormatFloat(f.MaxLat, 'f', -1, 64),
+		strconv.FormatFloat(f.MinLon, 'f', -1, 64),
+		strconv.FormatFloat(f.MaxLon, 'f', -1, 64),
+		base64.StdEncoding.EncodeToString(f.MMSI.bits),
+	}, "|")
+}
+
+// decodeShardFooter parses the line written by shardFooter.encode.
+func decodeShardFooter(line string) (shardFooter, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) != 7 {
+		return shardFooter{}, fmt.Errorf("decode shard footer: expected 7 fields, got %d", len(parts))
+	}
+	var f shardFooter
+	var err error
+	if f.MinTime, err = time.Parse(time.RFC3339, parts[0]); err != nil {
+		return shardFooter{}, err
+	}
+	if f.MaxTime, err = time.Parse(time.RFC3339, parts[1]); err != nil {
+		return shardFooter{}, err
+	}
+	if f.MinLat, err = strconv.ParseFloat(parts[2], 64); err != nil {
+		return shardFooter{}, err
+	}
+	if f.MaxLat, err = strconv.ParseFloat(parts[3], 64); err != nil {
+		return shardFooter{}, err
+	}
+	if f.MinLon, err = strconv.ParseFloat(parts[4], 64); err != nil {
+		return shardFooter{}, err
+	}
+	if f.MaxLon, err = strconv.ParseFloat(parts[5], 64); err != nil {
+		return shardFooter{}, err
+	}
+	bits, err := base64.StdEncoding.DecodeString(parts[6])
+	if err != nil {
+		return shardFooter{}, err
+	}
+	f.MMSI = &bloomFilter{bits: bits}
+	return f, nil
+}
+
+// SubsetRange returns a pointer to a new *RecordSet containing every record
+// matching m, using m's time range and bounding box to skip whole shards
+// whose footer cannot overlap it - and, when m implements MMSIMatching, its
+// shard's MMSI bloom filter to skip shards that provably do not contain the
+// vessel - before falling back to the exact per-record test in m.Match. It
+// only prunes shards on a RecordSet opened with OpenTSDBRecordSet; on any
+// other RecordSet it is equivalent to Subset.
+func (rs *RecordSet) SubsetRange(m RangeMatching) (*RecordSet, error) {
+	if rs.tsdb == nil {
+		return rs.Subset(m)
+	}
+
+	mmsiMatch, scopedToMMSI := m.(MMSIMatching)
+
+	rs2 := NewRecordSet()
+	rs2.SetHeaders(rs.Headers())
+
+	written := 0
+	for _, path := range rs.tsdb.shards {
+		_, footer, err := readShard(path, rs.tsdb.timeIndex, rs.tsdb.latIndex, rs.tsdb.lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("subsetrange: %v", err)
+		}
+		if !m.MatchRange(footer.MinTime, footer.MaxTime) {
+			continue
+		}
+		if !m.MatchBox(footer.MinLat, footer.MaxLat, footer.MinLon, footer.MaxLon) {
+			continue
+		}
+		if scopedToMMSI && footer.MMSI != nil && !footer.MMSI.MayContain(mmsiMatch.MMSI()) {
+			continue
+		}
+
+		recs, _, err := readShard(path, rs.tsdb.timeIndex, rs.tsdb.latIndex, rs.tsdb.lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("subsetrange: %v", err)
+		}
+		for _, rec := range recs {
+			ok, err := m.Match(&rec)
+			if err != nil {
+				return nil, fmt.Errorf("subsetrange: %v", err)
+			}
+			if !ok {
+				continue
+			}
+			if err := rs2.Write(rec); err != nil {
+				return nil, fmt.Errorf("subsetrange: %v", err)
+			}
+			written++
+		}
+	}
+	if err := rs2.Flush(); err != nil {
+		return nil, fmt.Errorf("subsetrange: %v", err)
+	}
+	if written == 0 {
+		return rs2, ErrEmptySet
+	}
+	return rs2, nil
+}
+
+// bloomFilter is a small fixed-size Bloom filter over string keys (MMSI
+// values), used in shard footers so SubsetRange can test, for an
+// MMSIMatching query, whether the MMSI is possibly present in a shard and
+// skip the shard's records when it is not. It
+// uses double hashing off two fnv64a hashes rather than computing k
+// independent hash functions, a standard space/time tradeoff for small
+// filters.
+type bloomFilter struct {
+	bits []byte
+}
+
+// newBloomFilter returns a bloomFilter with the given number of bits,
+// rounded up to a whole number of bytes.
+func newBloomFilter(nbits int) *bloomFilter {
+	return &bloomFilter{bits: make([]byte, (nbits+7)/8)}
+}
+
+// Add sets the bits in the filter corresponding to key.
+func (b *bloomFilter) Add(key string) {
+	h1, h2 := b.hashes(key)
+	for i := 0; i < 4; i++ {
+		idx := (h1 + uint64(i)*h2) % uint64(len(b.bits)*8)
+		b.bits[idx/8] |= 1 << uint(idx%8)
+	}
+}
+
+// MayContain reports whether key could be present in the filter. A false
+// return means key is definitely absent; a true return may be a false
+// positive.
+func (b *bloomFilter) MayContain(key string) bool {
+	h1, h2 := b.hashes(key)
+	for i := 0; i < 4; i++ {
+		idx := (h1 + uint64(i)*h2) % uint64(len(b.bits)*8)
+		if b.bits[idx/8]&(1<<uint(idx%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashes returns the two independent fnv64a hashes used to derive the k
+// bit positions for a key via double hashing.
+func (b *bloomFilter) hashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64a()
+	h2.Write([]byte(key))
+	h2.Write([]byte{0xff})
+	return h1.Sum64(), h2.Sum64()
+}